@@ -0,0 +1,168 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultBodyCaptureLimit = 4 * 1024
+
+// Redactor redacts sensitive data from a request or response body before it
+// is attached to a span. It receives the (possibly truncated) body bytes
+// along with the headers of the message they belong to, and returns the
+// bytes that should be tagged instead.
+type Redactor func(body []byte, headers http.Header) []byte
+
+type bodyCaptureConfig struct {
+	enabled   bool
+	limit     int
+	redactors []Redactor
+}
+
+type captureConfig struct {
+	request  bodyCaptureConfig
+	response bodyCaptureConfig
+	headers  headerCaptureConfig
+}
+
+type headerCaptureConfig struct {
+	enabled bool
+	allow   []string
+	redact  map[string]struct{}
+}
+
+// WithRequestBodyCapture enables tagging of outgoing request bodies on the
+// span, up to limit bytes. A limit of 0 uses a 4KB default. Only bodies
+// whose Content-Type looks textual or JSON are captured; others are
+// dropped. Use redactors to strip sensitive data (such as auth tokens or
+// PII) before it is attached to the span.
+func WithRequestBodyCapture(limit int, redactors ...Redactor) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		if limit <= 0 {
+			limit = defaultBodyCaptureLimit
+		}
+		cfg.capture.request = bodyCaptureConfig{enabled: true, limit: limit, redactors: redactors}
+	}
+}
+
+// WithResponseBodyCapture enables tagging of incoming response bodies on the
+// span, up to limit bytes. A limit of 0 uses a 4KB default. Only bodies
+// whose Content-Type looks textual or JSON are captured; others are
+// dropped. Use redactors to strip sensitive data (such as auth tokens or
+// PII) before it is attached to the span.
+func WithResponseBodyCapture(limit int, redactors ...Redactor) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		if limit <= 0 {
+			limit = defaultBodyCaptureLimit
+		}
+		cfg.capture.response = bodyCaptureConfig{enabled: true, limit: limit, redactors: redactors}
+	}
+}
+
+// WithHeaderCapture enables tagging of request/response headers on the span.
+// Only headers named in allow are captured; any header named in redact has
+// its value replaced with "redacted" rather than dropped entirely, so that
+// its presence remains visible.
+func WithHeaderCapture(allow []string, redact []string) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		redactSet := make(map[string]struct{}, len(redact))
+		for _, h := range redact {
+			redactSet[strings.ToLower(h)] = struct{}{}
+		}
+		cfg.capture.headers = headerCaptureConfig{enabled: true, allow: allow, redact: redactSet}
+	}
+}
+
+// isTextualContentType reports whether a Content-Type value is safe to
+// capture as a text span tag, i.e. it looks like text, JSON, or XML.
+func isTextualContentType(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.HasPrefix(ct, "text/"):
+		return true
+	case strings.Contains(ct, "json"):
+		return true
+	case strings.Contains(ct, "xml"):
+		return true
+	case strings.Contains(ct, "x-www-form-urlencoded"):
+		return true
+	default:
+		return false
+	}
+}
+
+// captureBody reads at most cfg.limit+1 bytes from r - enough to tag up to
+// cfg.limit bytes on the span and detect truncation - and returns a
+// ReadCloser that replays those bytes followed by the rest of r, streamed
+// lazily rather than buffered, so bodies larger than the configured limit
+// are never fully read into memory. tagged is nil when the content type
+// isn't textual or nothing should be captured. A non-nil error means r was
+// only partially consumed and should not be used any further.
+func (cfg bodyCaptureConfig) captureBody(r io.ReadCloser, headers http.Header) (tagged []byte, replay io.ReadCloser, err error) {
+	if r == nil || !cfg.enabled {
+		return nil, r, nil
+	}
+	if !isTextualContentType(headers.Get("Content-Type")) {
+		return nil, r, nil
+	}
+
+	buf := make([]byte, cfg.limit+1)
+	n, readErr := io.ReadFull(r, buf)
+	if readErr != nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+		return nil, nil, readErr
+	}
+
+	captured := buf[:n]
+	if n > cfg.limit {
+		captured = captured[:cfg.limit]
+	}
+	for _, redact := range cfg.redactors {
+		captured = redact(captured, headers)
+	}
+
+	replay = &bodyReplayer{Reader: io.MultiReader(bytes.NewReader(buf[:n]), r), closer: r}
+	return captured, replay, nil
+}
+
+// bodyReplayer prepends the already-consumed prefix of a body back onto its
+// (still open) source reader, so the rest streams through without being
+// buffered, while Close still closes the original source.
+type bodyReplayer struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (b *bodyReplayer) Close() error { return b.closer.Close() }
+
+// captureHeaders renders the allow-listed headers as a single span tag
+// value, redacting any configured sensitive header names.
+func (cfg headerCaptureConfig) captureHeaders(headers http.Header) string {
+	if !cfg.enabled || len(cfg.allow) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, name := range cfg.allow {
+		v := headers.Get(name)
+		if v == "" {
+			continue
+		}
+		if _, redact := cfg.redact[strings.ToLower(name)]; redact {
+			v = "redacted"
+		}
+		if b.Len() > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(v)
+	}
+	return b.String()
+}
@@ -0,0 +1,125 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureBodyWithinLimit(t *testing.T) {
+	cfg := bodyCaptureConfig{enabled: true, limit: 1024}
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	body := io.NopCloser(strings.NewReader(`{"hello":"world"}`))
+
+	tagged, replay, err := cfg.captureBody(body, headers)
+	require.NoError(t, err)
+	assert.Equal(t, `{"hello":"world"}`, string(tagged))
+
+	replayed, err := io.ReadAll(replay)
+	require.NoError(t, err)
+	assert.Equal(t, `{"hello":"world"}`, string(replayed))
+}
+
+func TestCaptureBodyTruncatesTagButReplaysEverything(t *testing.T) {
+	cfg := bodyCaptureConfig{enabled: true, limit: 4}
+	headers := http.Header{"Content-Type": []string{"text/plain"}}
+	body := io.NopCloser(strings.NewReader("hello world"))
+
+	tagged, replay, err := cfg.captureBody(body, headers)
+	require.NoError(t, err)
+	assert.Equal(t, "hell", string(tagged))
+
+	replayed, err := io.ReadAll(replay)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(replayed), "replay must contain the full body, not just the truncated tag")
+}
+
+// capCountingReader records how many bytes have been read from it so far,
+// letting a test snapshot that count at a specific point to prove
+// captureBody doesn't slurp bodies larger than its limit into memory up
+// front.
+type capCountingReader struct {
+	r        io.Reader
+	consumed int
+}
+
+func (c *capCountingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.consumed += n
+	return n, err
+}
+
+func TestCaptureBodyDoesNotBufferPastLimit(t *testing.T) {
+	cfg := bodyCaptureConfig{enabled: true, limit: 8}
+	headers := http.Header{"Content-Type": []string{"text/plain"}}
+	large := strings.Repeat("x", 1<<20)
+	cr := &capCountingReader{r: strings.NewReader(large)}
+
+	_, replay, err := cfg.captureBody(io.NopCloser(cr), headers)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, cr.consumed, cfg.limit+1,
+		"captureBody must not read past its limit before the caller pulls the rest via the replay reader")
+
+	// Only now does the rest of the body get pulled through.
+	replayed, err := io.ReadAll(replay)
+	require.NoError(t, err)
+	assert.Equal(t, large, string(replayed))
+}
+
+func TestCaptureBodySkipsNonTextualContentType(t *testing.T) {
+	cfg := bodyCaptureConfig{enabled: true, limit: 1024}
+	headers := http.Header{"Content-Type": []string{"application/octet-stream"}}
+	body := io.NopCloser(strings.NewReader("binary data"))
+
+	tagged, replay, err := cfg.captureBody(body, headers)
+	require.NoError(t, err)
+	assert.Nil(t, tagged)
+
+	replayed, err := io.ReadAll(replay)
+	require.NoError(t, err)
+	assert.Equal(t, "binary data", string(replayed))
+}
+
+func TestCaptureBodyAppliesRedactors(t *testing.T) {
+	cfg := bodyCaptureConfig{
+		enabled: true,
+		limit:   1024,
+		redactors: []Redactor{
+			func(body []byte, _ http.Header) []byte {
+				return []byte(strings.ReplaceAll(string(body), "secret", "redacted"))
+			},
+		},
+	}
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	body := io.NopCloser(strings.NewReader(`{"token":"secret"}`))
+
+	tagged, _, err := cfg.captureBody(body, headers)
+	require.NoError(t, err)
+	assert.Equal(t, `{"token":"redacted"}`, string(tagged))
+}
+
+func TestCaptureHeadersRedactsConfiguredNames(t *testing.T) {
+	cfg := headerCaptureConfig{
+		enabled: true,
+		allow:   []string{"X-Request-Id", "Authorization"},
+		redact:  map[string]struct{}{"authorization": {}},
+	}
+	headers := http.Header{
+		"X-Request-Id":  []string{"abc-123"},
+		"Authorization": []string{"Bearer token"},
+	}
+
+	out := cfg.captureHeaders(headers)
+	assert.Contains(t, out, "X-Request-Id=abc-123")
+	assert.Contains(t, out, "Authorization=redacted")
+	assert.NotContains(t, out, "Bearer token")
+}
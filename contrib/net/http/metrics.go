@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// HostMapper sanitizes a request host before it is used as a metric tag, so
+// that callers can collapse high-cardinality hosts (e.g. ones containing
+// IDs) into a bounded set of values. Returning "" drops the host tag
+// entirely.
+type HostMapper func(host string) string
+
+type metricsConfig struct {
+	enabled    bool
+	prefix     string
+	client     statsd.ClientInterface
+	hostMapper HostMapper
+}
+
+// WithMetrics enables emitting low-cardinality RED (rate/error/duration)
+// metrics to statsd alongside the spans created for every outgoing
+// request: <prefix>.request.duration, <prefix>.dns_lookup_time,
+// <prefix>.tls_handshake_time, <prefix>.connect_time,
+// <prefix>.time_to_first_byte, and connection reuse counters
+// <prefix>.connections.reused / <prefix>.connections.new. Metrics are
+// tagged by method, status_class and service; host is omitted unless a
+// HostMapper is supplied via WithHostMapper, to avoid unbounded tag
+// cardinality.
+func WithMetrics(prefix string, client statsd.ClientInterface) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.metrics.enabled = true
+		cfg.metrics.prefix = prefix
+		cfg.metrics.client = client
+	}
+}
+
+// WithHostMapper sets the HostMapper used to sanitize the "host" tag on
+// metrics emitted via WithMetrics. It has no effect unless WithMetrics is
+// also set.
+func WithHostMapper(m HostMapper) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.metrics.hostMapper = m
+	}
+}
+
+func statusClass(statusCode int) string {
+	if statusCode <= 0 {
+		return "unknown"
+	}
+	return strconv.Itoa(statusCode/100) + "xx"
+}
+
+// emit sends the RED metrics and httptrace-derived timing histograms for a
+// single request to statsd. It is a no-op when metrics aren't enabled.
+func (cfg metricsConfig) emit(req *http.Request, res *http.Response, httpErr error, elapsed time.Duration, trace httpTraceResult, serviceName string) {
+	if !cfg.enabled || cfg.client == nil {
+		return
+	}
+
+	tags := []string{
+		"method:" + req.Method,
+		"status_class:" + statusClass(statusCodeOf(res)),
+	}
+	if serviceName != "" {
+		tags = append(tags, "service:"+serviceName)
+	}
+	if cfg.hostMapper != nil {
+		if host := cfg.hostMapper(req.URL.Host); host != "" {
+			tags = append(tags, "host:"+host)
+		}
+	}
+
+	_ = cfg.client.Timing(cfg.prefix+".request.duration", elapsed, tags, 1)
+	if trace.DNSLookup > 0 {
+		_ = cfg.client.Timing(cfg.prefix+".dns_lookup_time", trace.DNSLookup, tags, 1)
+	}
+	if trace.isTLS {
+		_ = cfg.client.Timing(cfg.prefix+".tls_handshake_time", trace.TLSHandshake, tags, 1)
+	}
+	if trace.Connect > 0 {
+		_ = cfg.client.Timing(cfg.prefix+".connect_time", trace.Connect, tags, 1)
+	}
+	if trace.StartTransfer > 0 {
+		_ = cfg.client.Timing(cfg.prefix+".time_to_first_byte", trace.StartTransfer, tags, 1)
+	}
+
+	if trace.isReused {
+		_ = cfg.client.Incr(cfg.prefix+".connections.reused", tags, 1)
+	} else {
+		_ = cfg.client.Incr(cfg.prefix+".connections.new", tags, 1)
+	}
+}
+
+func statusCodeOf(res *http.Response) int {
+	if res == nil {
+		return 0
+	}
+	return res.StatusCode
+}
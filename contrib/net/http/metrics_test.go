@@ -0,0 +1,59 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// recordingStatsd implements only the statsd.ClientInterface methods that
+// metricsConfig.emit actually calls; embedding the interface satisfies the
+// rest so this compiles without stubbing dozens of unused methods.
+type recordingStatsd struct {
+	statsd.ClientInterface
+	timings []string
+	incrs   []string
+}
+
+func (r *recordingStatsd) Timing(name string, _ time.Duration, tags []string, _ float64) error {
+	r.timings = append(r.timings, name)
+	return nil
+}
+
+func (r *recordingStatsd) Incr(name string, tags []string, _ float64) error {
+	r.incrs = append(r.incrs, name)
+	return nil
+}
+
+func TestWithHostMapperBeforeWithMetricsIsNotLost(t *testing.T) {
+	cfg := newRoundTripperConfig()
+	WithHostMapper(func(string) string { return "mapped" })(cfg)
+	WithMetrics("http.client", &recordingStatsd{})(cfg)
+
+	require.NotNil(t, cfg.metrics.hostMapper)
+	assert.Equal(t, "mapped", cfg.metrics.hostMapper("anything"))
+}
+
+func TestMetricsEmitTagsConnectionReuse(t *testing.T) {
+	client := &recordingStatsd{}
+	cfg := metricsConfig{enabled: true, prefix: "http.client", client: client}
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	require.NoError(t, err)
+	res := &http.Response{StatusCode: 200}
+
+	cfg.emit(req, res, nil, 10*time.Millisecond, httpTraceResult{isReused: true}, "my-service")
+
+	assert.Contains(t, client.timings, "http.client.request.duration")
+	assert.Contains(t, client.incrs, "http.client.connections.reused")
+	assert.NotContains(t, client.incrs, "http.client.connections.new")
+}
@@ -0,0 +1,113 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"math"
+	"net/http"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// RoundTripperBeforeFunc defines a function type that is called before the
+// request is sent over the wire.
+type RoundTripperBeforeFunc func(*http.Request, ddtrace.Span)
+
+// RoundTripperAfterFunc defines a function type that is called after the
+// request has been handled.
+type RoundTripperAfterFunc func(*http.Response, ddtrace.Span)
+
+type roundTripperConfig struct {
+	before        RoundTripperBeforeFunc
+	after         RoundTripperAfterFunc
+	analyticsRate float64
+	serviceName   string
+	retry         *retryConfig
+	capture       captureConfig
+	propagators   []tracer.Propagator
+	// replaceDefaultPropagator is true when WithPropagator was used: the
+	// tracer's default (Datadog) propagator is skipped in favor of
+	// cfg.propagators. WithPropagators leaves it false so its propagators
+	// are injected alongside the default one.
+	replaceDefaultPropagator bool
+	metrics                  metricsConfig
+
+	// tlsClientHelloID is set internally by WrapUTLSRoundTripper; it is not
+	// a public option since the ClientHelloID is a required constructor
+	// argument, not an opt-in behaviour.
+	tlsClientHelloID string
+
+	forceHTTP1 bool
+}
+
+// WithForceHTTP1 disables HTTP/2 on the wrapped transport, cloning it and
+// clearing its ALPN protocol negotiation so that all requests are forced
+// onto HTTP/1.1. This mirrors a workaround commonly needed to sidestep
+// problematic H2 connection-reuse behavior (see golang/go#59690). Only an
+// *http.Transport is affected: other RoundTrippers passed to
+// WrapRoundTripper are used unchanged, and WrapUTLSRoundTripper ignores
+// this option.
+func WithForceHTTP1() RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.forceHTTP1 = true
+	}
+}
+
+func newRoundTripperConfig() *roundTripperConfig {
+	return &roundTripperConfig{
+		analyticsRate: math.NaN(),
+	}
+}
+
+// RoundTripperOption describes options for http.RoundTripper.
+type RoundTripperOption func(*roundTripperConfig)
+
+// WithBefore adds a RoundTripperBeforeFunc to the RoundTripper
+// config.
+func WithBefore(f RoundTripperBeforeFunc) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.before = f
+	}
+}
+
+// WithAfter adds a RoundTripperAfterFunc to the RoundTripper
+// config.
+func WithAfter(f RoundTripperAfterFunc) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.after = f
+	}
+}
+
+// WithAnalytics enables/disables default analytics support.
+func WithAnalytics(on bool) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		if on {
+			cfg.analyticsRate = 1.0
+		} else {
+			cfg.analyticsRate = math.NaN()
+		}
+	}
+}
+
+// WithAnalyticsRate sets the sampling rate for Trace Analytics events
+// correlated to started spans.
+func WithAnalyticsRate(rate float64) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		if rate >= 0.0 && rate <= 1.0 {
+			cfg.analyticsRate = rate
+		} else {
+			cfg.analyticsRate = math.NaN()
+		}
+	}
+}
+
+// WithServiceName sets the given service name for the RoundTripper.
+func WithServiceName(name string) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.serviceName = name
+	}
+}
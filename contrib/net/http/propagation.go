@@ -0,0 +1,172 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+	baggageHeader     = "baggage"
+)
+
+// W3CPropagator is a tracer.Propagator which injects and extracts the
+// "traceparent" header as defined by the W3C Trace Context specification.
+// The "tracestate" header is neither written by Inject nor read by Extract.
+// It is meant to be used with WithPropagator or WithPropagators to let the
+// traced RoundTripper interoperate with non-Datadog, W3C-aware backends.
+type W3CPropagator struct {
+	// Baggage, when true, additionally injects the W3C "baggage" header.
+	// Extract does not read it.
+	Baggage bool
+}
+
+// Inject implements tracer.Propagator.
+func (p *W3CPropagator) Inject(ctx ddtrace.SpanContext, carrier interface{}) error {
+	writer, ok := carrier.(tracer.TextMapWriter)
+	if !ok {
+		return tracer.ErrInvalidCarrier
+	}
+	if ctx.TraceID() == 0 {
+		return tracer.ErrInvalidSpanContext
+	}
+
+	// Contexts carrying a 128-bit trace ID (the tracer's default) implement
+	// ddtrace.SpanContextW3C; use its full, already 32-hex-char value so the
+	// upper 64 bits aren't silently zeroed out. Contexts that only have a
+	// 64-bit trace ID fall back to zero-padding it to the traceparent
+	// spec's required 32 hex characters.
+	traceID := fmt.Sprintf("%032x", ctx.TraceID())
+	if w3c, ok := ctx.(ddtrace.SpanContextW3C); ok {
+		if full := w3c.TraceID128(); full != "" {
+			traceID = full
+		}
+	}
+	spanID := fmt.Sprintf("%016x", ctx.SpanID())
+	writer.Set(traceparentHeader, fmt.Sprintf("00-%s-%s-01", traceID, spanID))
+
+	if p.Baggage {
+		var pairs []string
+		ctx.ForeachBaggageItem(func(k, v string) bool {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+			return true
+		})
+		if len(pairs) > 0 {
+			writer.Set(baggageHeader, strings.Join(pairs, ","))
+		}
+	}
+	return nil
+}
+
+// Extract implements tracer.Propagator.
+func (p *W3CPropagator) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	reader, ok := carrier.(tracer.TextMapReader)
+	if !ok {
+		return nil, tracer.ErrInvalidCarrier
+	}
+
+	var traceparent string
+	if err := reader.ForeachKey(func(k, v string) error {
+		if strings.EqualFold(k, traceparentHeader) {
+			traceparent = v
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if traceparent == "" {
+		return nil, tracer.ErrSpanContextNotFound
+	}
+
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return nil, tracer.ErrSpanContextCorrupted
+	}
+	traceIDBytes, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return nil, tracer.ErrSpanContextCorrupted
+	}
+	spanIDBytes, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return nil, tracer.ErrSpanContextCorrupted
+	}
+	// traceIDBytes holds the full 128 bits; keep all of it (via traceID128)
+	// for interop with W3C backends, and also derive the lower 64 bits
+	// (traceID) since that's all ddtrace.SpanContext itself exposes.
+	var traceID, spanID uint64
+	for _, b := range traceIDBytes[8:] {
+		traceID = traceID<<8 | uint64(b)
+	}
+	for _, b := range spanIDBytes {
+		spanID = spanID<<8 | uint64(b)
+	}
+	return &w3cSpanContext{traceID: traceID, traceID128: strings.ToLower(parts[1]), spanID: spanID}, nil
+}
+
+// w3cSpanContext is a minimal ddtrace.SpanContext carrying the trace and
+// span IDs decoded from an inbound "traceparent" header; it carries no
+// baggage of its own. It also implements ddtrace.SpanContextW3C so the full
+// 128-bit trace ID survives an extract-then-inject round trip instead of
+// being truncated to its lower 64 bits.
+type w3cSpanContext struct {
+	traceID    uint64
+	traceID128 string
+	spanID     uint64
+}
+
+func (c *w3cSpanContext) TraceID() uint64                                   { return c.traceID }
+func (c *w3cSpanContext) SpanID() uint64                                    { return c.spanID }
+func (c *w3cSpanContext) ForeachBaggageItem(handler func(k, v string) bool) {}
+
+// TraceID128 implements ddtrace.SpanContextW3C.
+func (c *w3cSpanContext) TraceID128() string { return c.traceID128 }
+
+// TraceID128Bytes implements ddtrace.SpanContextW3C.
+func (c *w3cSpanContext) TraceID128Bytes() [16]byte {
+	var b [16]byte
+	raw, _ := hex.DecodeString(c.traceID128)
+	copy(b[16-len(raw):], raw)
+	return b
+}
+
+// WithPropagator sets the propagator used to inject tracing headers into
+// outgoing requests, replacing the tracer's default (Datadog) propagator.
+func WithPropagator(p tracer.Propagator) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.propagators = []tracer.Propagator{p}
+		cfg.replaceDefaultPropagator = true
+	}
+}
+
+// WithPropagators injects multiple propagators alongside the tracer's
+// default (Datadog) propagator, e.g. to run Datadog, B3, and W3C headers
+// side by side while migrating a polyglot fleet of services.
+func WithPropagators(p ...tracer.Propagator) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.propagators = p
+		cfg.replaceDefaultPropagator = false
+	}
+}
+
+// injectPropagators injects the span context into req.Header using every
+// configured propagator, returning the first error encountered, if any.
+func injectPropagators(propagators []tracer.Propagator, ctx ddtrace.SpanContext, header http.Header) error {
+	var firstErr error
+	for _, p := range propagators {
+		if err := p.Inject(ctx, tracer.HTTPHeadersCarrier(header)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
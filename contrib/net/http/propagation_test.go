@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+func TestW3CPropagatorInjectExtractRoundTrip(t *testing.T) {
+	p := &W3CPropagator{}
+	sc := &w3cSpanContext{traceID: 0x1, spanID: 0x2}
+	header := http.Header{}
+
+	require.NoError(t, p.Inject(sc, tracer.HTTPHeadersCarrier(header)))
+	assert.NotEmpty(t, header.Get("traceparent"))
+
+	extracted, err := p.Extract(tracer.HTTPHeadersCarrier(header))
+	require.NoError(t, err)
+	assert.Equal(t, sc.TraceID(), extracted.TraceID())
+	assert.Equal(t, sc.SpanID(), extracted.SpanID())
+}
+
+func TestW3CPropagatorInjectUsesFull128BitTraceID(t *testing.T) {
+	p := &W3CPropagator{}
+	sc := &w3cSpanContext{traceID: 0x69a96460a57af1c0, traceID128: "6a65dd180000000069a96460a57af1c0", spanID: 0x2}
+	header := http.Header{}
+
+	require.NoError(t, p.Inject(sc, tracer.HTTPHeadersCarrier(header)))
+
+	assert.Contains(t, header.Get("traceparent"), "6a65dd180000000069a96460a57af1c0",
+		"the upper 64 bits of a 128-bit trace ID must not be zero-padded away")
+}
+
+func TestW3CPropagatorExtractPreservesFull128BitTraceID(t *testing.T) {
+	p := &W3CPropagator{}
+	header := http.Header{"Traceparent": []string{"00-6a65dd180000000069a96460a57af1c0-0000000000000002-01"}}
+
+	extracted, err := p.Extract(tracer.HTTPHeadersCarrier(header))
+	require.NoError(t, err)
+
+	w3c, ok := extracted.(ddtrace.SpanContextW3C)
+	require.True(t, ok, "extracted context should implement ddtrace.SpanContextW3C")
+	assert.Equal(t, "6a65dd180000000069a96460a57af1c0", w3c.TraceID128())
+	assert.Equal(t, uint64(0x69a96460a57af1c0), extracted.TraceID(), "TraceID() should still return the lower 64 bits")
+}
+
+func TestW3CPropagatorExtractMissingHeader(t *testing.T) {
+	p := &W3CPropagator{}
+	_, err := p.Extract(tracer.HTTPHeadersCarrier(http.Header{}))
+	assert.Equal(t, tracer.ErrSpanContextNotFound, err)
+}
+
+type stubPropagator struct {
+	injectErr error
+	injected  bool
+}
+
+func (s *stubPropagator) Inject(ddtrace.SpanContext, interface{}) error {
+	s.injected = true
+	return s.injectErr
+}
+
+func (s *stubPropagator) Extract(interface{}) (ddtrace.SpanContext, error) {
+	return nil, nil
+}
+
+func TestInjectPropagatorsRunsAllAndReturnsFirstError(t *testing.T) {
+	first := &stubPropagator{injectErr: errors.New("boom")}
+	second := &stubPropagator{}
+	sc := &w3cSpanContext{traceID: 1, spanID: 2}
+
+	err := injectPropagators([]tracer.Propagator{first, second}, sc, http.Header{})
+
+	assert.Equal(t, first.injectErr, err)
+	assert.True(t, first.injected)
+	assert.True(t, second.injected, "later propagators must still run after an earlier one errors")
+}
@@ -0,0 +1,103 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// BackoffFunc computes the delay to wait before the given retry attempt
+// (attempt is 1-indexed: 1 is the delay before the first retry).
+type BackoffFunc func(attempt int) time.Duration
+
+// DefaultBackoff returns a BackoffFunc implementing a simple exponential
+// backoff, doubling the delay on every attempt starting from base and
+// never exceeding max.
+func DefaultBackoff(base, max time.Duration) BackoffFunc {
+	return func(attempt int) time.Duration {
+		d := base << uint(attempt-1)
+		if d <= 0 || d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// errNonRewindableBody is returned when a request with a non-nil body needs
+// to be retried but does not provide a GetBody function to rewind it.
+var errNonRewindableBody = errors.New("contrib/net/http: cannot retry request: body is not rewindable (req.GetBody is nil)")
+
+type retryConfig struct {
+	maxAttempts int
+	backoff     BackoffFunc
+	retryOn     func(*http.Response, error) bool
+}
+
+// WithRetry enables retrying of requests sent through the traced
+// RoundTripper. maxAttempts is the total number of attempts allowed
+// (including the first one); values below 1 are treated as 1, i.e. the
+// request is always attempted at least once and simply never retried.
+// backoff computes the delay before each retry; retryOn decides, given the
+// response and/or error of an attempt, whether it should be retried. If the
+// request has a non-nil body, req.GetBody must be set so it can be
+// rewound between attempts.
+func WithRetry(maxAttempts int, backoff BackoffFunc, retryOn func(*http.Response, error) bool) RoundTripperOption {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return func(cfg *roundTripperConfig) {
+		cfg.retry = &retryConfig{
+			maxAttempts: maxAttempts,
+			backoff:     backoff,
+			retryOn:     retryOn,
+		}
+	}
+}
+
+// retryAfterDelay parses the Retry-After header of a 429/503 response,
+// returning the delay it indicates and whether one was present.
+func retryAfterDelay(res *http.Response) (time.Duration, bool) {
+	if res == nil || (res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// rewindBody returns a request ready to be sent again: req itself when it
+// has no body, otherwise a clone whose body comes from req.GetBody. It
+// requires req.GetBody to be set whenever the original request had a
+// non-nil body.
+func rewindBody(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, errNonRewindableBody
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("contrib/net/http: failed to rewind request body: %w", err)
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return clone, nil
+}
@@ -0,0 +1,85 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRetryFloorsMaxAttemptsAtOne(t *testing.T) {
+	for _, maxAttempts := range []int{0, -1, -100} {
+		cfg := newRoundTripperConfig()
+		WithRetry(maxAttempts, DefaultBackoff(time.Millisecond, time.Second), nil)(cfg)
+		require.NotNil(t, cfg.retry)
+		assert.Equal(t, 1, cfg.retry.maxAttempts, "maxAttempts=%d should floor to 1", maxAttempts)
+	}
+}
+
+func TestDefaultBackoffDoublesUpToMax(t *testing.T) {
+	backoff := DefaultBackoff(10*time.Millisecond, 100*time.Millisecond)
+
+	assert.Equal(t, 10*time.Millisecond, backoff(1))
+	assert.Equal(t, 20*time.Millisecond, backoff(2))
+	assert.Equal(t, 40*time.Millisecond, backoff(3))
+	assert.Equal(t, 100*time.Millisecond, backoff(10), "should cap at max")
+}
+
+func TestRewindBodyRequiresGetBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", newNopGetBodyReader("payload"))
+	require.NoError(t, err)
+	req.GetBody = nil
+
+	_, err = rewindBody(req)
+	assert.ErrorIs(t, err, errNonRewindableBody)
+}
+
+func TestRewindBodyClonesViaGetBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("payload"))
+	require.NoError(t, err)
+	require.NotNil(t, req.GetBody, "http.NewRequest sets GetBody for strings.Reader bodies")
+
+	clone, err := rewindBody(req)
+	require.NoError(t, err)
+	assert.NotSame(t, req, clone)
+}
+
+func TestRetryAfterDelayParsesSeconds(t *testing.T) {
+	res := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"2"}},
+	}
+	d, ok := retryAfterDelay(res)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, d)
+}
+
+func TestRetryAfterDelayIgnoredForOtherStatusCodes(t *testing.T) {
+	res := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Retry-After": []string{"2"}},
+	}
+	_, ok := retryAfterDelay(res)
+	assert.False(t, ok)
+}
+
+// newNopGetBodyReader wraps a string in an io.ReadCloser that isn't one of
+// the concrete types http.NewRequest special-cases to auto-populate
+// GetBody, so it can be used to exercise the no-GetBody error path.
+func newNopGetBodyReader(s string) *nopGetBodyReader {
+	return &nopGetBodyReader{Reader: strings.NewReader(s)}
+}
+
+type nopGetBodyReader struct {
+	*strings.Reader
+}
+
+func (r *nopGetBodyReader) Close() error { return nil }
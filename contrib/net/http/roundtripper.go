@@ -13,6 +13,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptrace"
+	"net/textproto"
 	"os"
 	"strconv"
 	"time"
@@ -30,6 +31,57 @@ type roundTripper struct {
 }
 
 func (rt *roundTripper) RoundTrip(req *http.Request) (res *http.Response, err error) {
+	if rt.cfg.retry == nil {
+		return rt.roundTrip(req, 0)
+	}
+	return rt.roundTripWithRetry(req)
+}
+
+// roundTripWithRetry sends the request up to cfg.retry.maxAttempts times in
+// total (the first attempt included), re-sending it as long as cfg.retry.retryOn indicates the previous attempt should be
+// retried and the request's context hasn't been cancelled.
+func (rt *roundTripper) roundTripWithRetry(req *http.Request) (res *http.Response, err error) {
+	retry := rt.cfg.retry
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			if attemptReq, err = rewindBody(req); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err = rt.roundTrip(attemptReq, attempt)
+
+		if attempt >= retry.maxAttempts || retry.retryOn == nil || !retry.retryOn(res, err) {
+			return res, err
+		}
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			return res, err
+		}
+
+		delay := retry.backoff(attempt)
+		if d, ok := retryAfterDelay(res); ok {
+			delay = d
+		}
+		if res != nil && res.Body != nil {
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return res, err
+		}
+	}
+}
+
+// roundTrip performs a single attempt of the request, creating and finishing
+// a span for it. attempt is 0 when retries are disabled, and 1-indexed
+// otherwise; in the latter case the span is tagged with retry attempt
+// information.
+func (rt *roundTripper) roundTrip(req *http.Request, attempt int) (res *http.Response, err error) {
 	opts := []ddtrace.StartSpanOption{
 		tracer.SpanType(ext.SpanTypeHTTP),
 		tracer.ResourceName(defaultResourceName),
@@ -44,23 +96,54 @@ func (rt *roundTripper) RoundTrip(req *http.Request) (res *http.Response, err er
 		opts = append(opts, tracer.ServiceName(rt.cfg.serviceName))
 	}
 	span, ctx := tracer.StartSpanFromContext(req.Context(), defaultResourceName, opts...)
+	if attempt > 0 {
+		span.SetTag("http.retry.attempt", attempt)
+		span.SetTag("http.retry.max", rt.cfg.retry.maxAttempts)
+	}
+	start := time.Now()
+	var httpTraceResult httpTraceResult
 	defer func() {
+		rt.cfg.metrics.emit(req, res, err, time.Since(start), httpTraceResult, rt.cfg.serviceName)
 		if rt.cfg.after != nil {
 			rt.cfg.after(res, span)
 		}
+		if attempt > 0 {
+			span.SetTag("http.retry.elapsed_ms", time.Since(start).Milliseconds())
+			if res != nil {
+				span.SetTag("http.retry.status", res.StatusCode)
+			}
+		}
 		span.Finish(tracer.WithError(err))
 	}()
 	if rt.cfg.before != nil {
 		rt.cfg.before(req, span)
 	}
+	if h := rt.cfg.capture.headers.captureHeaders(req.Header); h != "" {
+		span.SetTag("http.request.headers", h)
+	}
+	body, replay, captureErr := rt.cfg.capture.request.captureBody(req.Body, req.Header)
+	if captureErr != nil {
+		err = fmt.Errorf("contrib/net/http.Roundtrip: failed to capture request body: %w", captureErr)
+		return nil, err
+	}
+	req.Body = replay
+	if body != nil {
+		span.SetTag("http.request.body", string(body))
+	}
 
 	// Inject Go's "httptrace" context into the request
-	var httpTraceResult httpTraceResult
 	ctx = WithClientTrace(ctx, &httpTraceResult)
 
-	err = tracer.Inject(span.Context(), tracer.HTTPHeadersCarrier(req.Header))
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "contrib/net/http.Roundtrip: failed to inject http headers: %v\n", err)
+	if !rt.cfg.replaceDefaultPropagator {
+		err = tracer.Inject(span.Context(), tracer.HTTPHeadersCarrier(req.Header))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "contrib/net/http.Roundtrip: failed to inject http headers: %v\n", err)
+		}
+	}
+	if len(rt.cfg.propagators) > 0 {
+		if err := injectPropagators(rt.cfg.propagators, span.Context(), req.Header); err != nil {
+			fmt.Fprintf(os.Stderr, "contrib/net/http.Roundtrip: failed to inject propagator headers: %v\n", err)
+		}
 	}
 
 	res, err = rt.base.RoundTrip(req.WithContext(ctx))
@@ -77,10 +160,38 @@ func (rt *roundTripper) RoundTrip(req *http.Request) (res *http.Response, err er
 		span.SetTag("http.starttransfer_time", httpTraceResult.StartTransfer.Nanoseconds())
 		span.SetTag("http.is_tls", httpTraceResult.isTLS)
 		span.SetTag("http.is_reused", httpTraceResult.isReused)
+		span.SetTag("http.version", res.Proto)
+		if httpTraceResult.negotiatedProto != "" {
+			span.SetTag("http.protocol.negotiated", httpTraceResult.negotiatedProto)
+		}
+		// The h2 stream ID isn't exposed by net/http; only tag it when a
+		// server or proxy echoes it back explicitly.
+		if httpTraceResult.multiplexed {
+			if id := res.Header.Get("X-Http2-Stream-Id"); id != "" {
+				span.SetTag("http.h2.stream_id", id)
+			}
+		}
+		if httpTraceResult.got100Continue {
+			span.SetTag("http.wait_100_continue_time", httpTraceResult.wait100Continue.Nanoseconds())
+		}
 
 		if httpTraceResult.isTLS {
 			span.SetTag("http.tls_handshake_time", httpTraceResult.TLSHandshake.Nanoseconds())
 		}
+		if rt.cfg.tlsClientHelloID != "" {
+			span.SetTag("http.tls.client_hello_id", rt.cfg.tlsClientHelloID)
+		}
+		if h := rt.cfg.capture.headers.captureHeaders(res.Header); h != "" {
+			span.SetTag("http.response.headers", h)
+		}
+		if body, replay, captureErr := rt.cfg.capture.response.captureBody(res.Body, res.Header); captureErr != nil {
+			fmt.Fprintf(os.Stderr, "contrib/net/http.Roundtrip: failed to capture response body: %v\n", captureErr)
+		} else {
+			res.Body = replay
+			if body != nil {
+				span.SetTag("http.response.body", string(body))
+			}
+		}
 
 		// treat 5XX as errors
 		if res.StatusCode/100 == 5 {
@@ -101,12 +212,47 @@ func WrapRoundTripper(rt http.RoundTripper, opts ...RoundTripperOption) http.Rou
 	if wrapped, ok := rt.(*roundTripper); ok {
 		rt = wrapped.base
 	}
+	if cfg.forceHTTP1 {
+		rt = disableHTTP2(rt)
+	}
 	return &roundTripper{
 		base: rt,
 		cfg:  cfg,
 	}
 }
 
+// disableHTTP2 clones base, if it's an *http.Transport, and clears its ALPN
+// next-protocol negotiation so that it never upgrades connections to
+// HTTP/2. Non-*http.Transport RoundTrippers are returned unchanged, since
+// there is no generic way to force them onto HTTP/1.1.
+func disableHTTP2(base http.RoundTripper) http.RoundTripper {
+	t, ok := base.(*http.Transport)
+	if !ok {
+		return base
+	}
+	t = t.Clone()
+	t.ForceAttemptHTTP2 = false
+	t.TLSNextProto = make(map[string]func(authority string, c *tls.Conn) http.RoundTripper)
+	if t.TLSClientConfig != nil && len(t.TLSClientConfig.NextProtos) > 0 {
+		cfg := t.TLSClientConfig.Clone()
+		cfg.NextProtos = withoutH2(cfg.NextProtos)
+		t.TLSClientConfig = cfg
+	}
+	return t
+}
+
+// withoutH2 returns protos with any "h2" entry removed, so that ALPN
+// negotiation can no longer select HTTP/2.
+func withoutH2(protos []string) []string {
+	out := make([]string, 0, len(protos))
+	for _, p := range protos {
+		if p != "h2" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
 // WrapClient modifies the given client's transport to augment it with tracing and returns it.
 func WrapClient(c *http.Client, opts ...RoundTripperOption) *http.Client {
 	if c.Transport == nil {
@@ -134,10 +280,15 @@ type httpTraceResult struct {
 	serverStart      time.Time
 	serverDone       time.Time
 	transferStart    time.Time
+	headersWritten   time.Time
 	isTLS            bool
 	isReused         bool
 	remoteIP         string
 	remotePort       string
+	negotiatedProto  string
+	multiplexed      bool
+	got100Continue   bool
+	wait100Continue  time.Duration
 }
 
 func WithClientTrace(ctx context.Context, r *httpTraceResult) context.Context {
@@ -166,10 +317,12 @@ func WithClientTrace(ctx context.Context, r *httpTraceResult) context.Context {
 			r.isTLS = true
 			r.tlsStart = time.Now()
 		},
-		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+		TLSHandshakeDone: func(cs tls.ConnectionState, _ error) {
 			r.tlsDone = time.Now()
 			r.TLSHandshake = r.tlsDone.Sub(r.tlsStart)
 			r.Pretransfer = r.tlsDone.Sub(r.dnsStart)
+			r.negotiatedProto = cs.NegotiatedProtocol
+			r.multiplexed = cs.NegotiatedProtocol == "h2"
 		},
 		GotConn: func(i httptrace.GotConnInfo) {
 			if i.Reused {
@@ -178,6 +331,18 @@ func WithClientTrace(ctx context.Context, r *httpTraceResult) context.Context {
 
 			r.remoteIP, r.remotePort, _ = net.SplitHostPort(i.Conn.RemoteAddr().String())
 		},
+		WroteHeaders: func() {
+			r.headersWritten = time.Now()
+		},
+		Got1xxResponse: func(code int, _ textproto.MIMEHeader) error {
+			if code == http.StatusContinue {
+				r.got100Continue = true
+				if !r.headersWritten.IsZero() {
+					r.wait100Continue = time.Since(r.headersWritten)
+				}
+			}
+			return nil
+		},
 
 		WroteRequest: func(info httptrace.WroteRequestInfo) {
 			r.serverStart = time.Now()
@@ -0,0 +1,51 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisableHTTP2ClearsALPNAndNextProto(t *testing.T) {
+	base := &http.Transport{
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{NextProtos: []string{"h2", "http/1.1"}},
+	}
+
+	out := disableHTTP2(base)
+
+	t2, ok := out.(*http.Transport)
+	require.True(t, ok)
+	assert.False(t, t2.ForceAttemptHTTP2)
+	assert.Empty(t, t2.TLSNextProto)
+	assert.Equal(t, []string{"http/1.1"}, t2.TLSClientConfig.NextProtos)
+	assert.NotSame(t, base, t2, "should clone rather than mutate the caller's transport")
+}
+
+func TestDisableHTTP2LeavesNonTransportRoundTrippersUnchanged(t *testing.T) {
+	base := http.RoundTripper(http.DefaultTransport)
+	custom := &fakeRoundTripper{}
+
+	out := disableHTTP2(custom)
+
+	assert.Same(t, custom, out)
+	_ = base
+}
+
+type fakeRoundTripper struct{}
+
+func (f *fakeRoundTripper) RoundTrip(*http.Request) (*http.Response, error) { return nil, nil }
+
+func TestWithoutH2RemovesOnlyH2(t *testing.T) {
+	assert.Equal(t, []string{"http/1.1"}, withoutH2([]string{"h2", "http/1.1"}))
+	assert.Equal(t, []string{}, withoutH2([]string{"h2"}))
+	assert.Equal(t, []string{"http/1.1"}, withoutH2([]string{"http/1.1"}))
+}
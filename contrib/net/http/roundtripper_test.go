@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// erroringReadCloser fails every Read with errRead, the first one included,
+// simulating a body that cannot be read.
+type erroringReadCloser struct{}
+
+var errRead = errors.New("boom: body read failed")
+
+func (erroringReadCloser) Read([]byte) (int, error) { return 0, errRead }
+func (erroringReadCloser) Close() error             { return nil }
+
+func TestRoundTripAbortsOnRequestBodyCaptureError(t *testing.T) {
+	var served bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}))
+	defer srv.Close()
+
+	rt := &roundTripper{
+		base: http.DefaultTransport,
+		cfg:  newRoundTripperConfig(),
+	}
+	rt.cfg.capture.request = bodyCaptureConfig{enabled: true, limit: 1024}
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, erroringReadCloser{})
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+
+	res, err := rt.RoundTrip(req)
+
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, errRead)
+	assert.False(t, served, "the request must not be sent once its body fails to capture")
+}
@@ -0,0 +1,224 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+// WrapUTLSRoundTripper returns a traced http.RoundTripper that performs its
+// outbound TLS handshakes using refraction-networking/utls with the given
+// ClientHelloID instead of the standard library's crypto/tls, so that
+// callers can control their outbound TLS fingerprint while keeping full
+// Datadog visibility. HTTP/2 is negotiated transparently via ALPN: once the
+// uTLS handshake completes, the connection is handed off to
+// golang.org/x/net/http2 whenever "h2" was negotiated.
+//
+// base is used to satisfy plain (non-TLS) requests; pass nil to use
+// http.DefaultTransport. cfg may be nil, in which case a zero-value
+// *utls.Config is used.
+func WrapUTLSRoundTripper(base http.RoundTripper, helloID utls.ClientHelloID, cfg *utls.Config, opts ...RoundTripperOption) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if cfg == nil {
+		cfg = &utls.Config{}
+	}
+
+	urt := &utlsRoundTripper{
+		base:    base,
+		helloID: helloID,
+		tlsCfg:  cfg,
+		h2:      &http2.Transport{},
+	}
+
+	rtCfg := newRoundTripperConfig()
+	for _, opt := range opts {
+		opt(rtCfg)
+	}
+	rtCfg.tlsClientHelloID = helloID.Client
+
+	return &roundTripper{base: urt, cfg: rtCfg}
+}
+
+// utlsRoundTripper dials TLS connections via uTLS and dispatches them to
+// either a manual HTTP/1.1 exchange or an HTTP/2 client connection,
+// depending on the protocol negotiated over ALPN.
+type utlsRoundTripper struct {
+	base    http.RoundTripper
+	helloID utls.ClientHelloID
+	tlsCfg  *utls.Config
+	h2      *http2.Transport
+
+	mu      sync.Mutex
+	h2Conns map[string]*http2.ClientConn
+}
+
+func (rt *utlsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return rt.base.RoundTrip(req)
+	}
+
+	addr := req.URL.Host
+	if !strings.Contains(addr, ":") {
+		addr += ":443"
+	}
+
+	if cc := rt.pooledH2Conn(addr); cc != nil {
+		return cc.RoundTrip(req)
+	}
+
+	trace := httptrace.ContextClientTrace(req.Context())
+	conn, err := rt.dialTLS(req.Context(), addr, trace)
+	if err != nil {
+		return nil, err
+	}
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: conn})
+	}
+
+	if conn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+		cc, err := rt.h2.NewClientConn(conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		rt.storeH2Conn(addr, cc)
+		return cc.RoundTrip(req)
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if trace != nil && trace.WroteRequest != nil {
+		trace.WroteRequest(httptrace.WroteRequestInfo{})
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// http.ReadResponse's Body has no reference to conn, so closing it
+	// alone would leak the underlying socket; closeOnceConnBody ties the
+	// two together.
+	resp.Body = &closeOnceConnBody{ReadCloser: resp.Body, conn: conn}
+	if trace != nil && trace.GotFirstResponseByte != nil {
+		trace.GotFirstResponseByte()
+	}
+	return resp, nil
+}
+
+// pooledH2Conn returns a cached, still-usable *http2.ClientConn for addr, if
+// any, evicting it first if it can no longer take new requests (e.g. it was
+// closed by the peer via GOAWAY).
+func (rt *utlsRoundTripper) pooledH2Conn(addr string) *http2.ClientConn {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	cc, ok := rt.h2Conns[addr]
+	if !ok {
+		return nil
+	}
+	if !cc.CanTakeNewRequest() {
+		delete(rt.h2Conns, addr)
+		return nil
+	}
+	return cc
+}
+
+// storeH2Conn caches cc so subsequent requests to addr can reuse it instead
+// of dialing and handshaking a new connection.
+func (rt *utlsRoundTripper) storeH2Conn(addr string, cc *http2.ClientConn) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.h2Conns == nil {
+		rt.h2Conns = make(map[string]*http2.ClientConn)
+	}
+	rt.h2Conns[addr] = cc
+}
+
+// closeOnceConnBody closes conn the first time the response body is closed,
+// so a single HTTP/1.1 connection's lifetime is tied to its response body
+// instead of being leaked.
+type closeOnceConnBody struct {
+	io.ReadCloser
+	conn net.Conn
+	once sync.Once
+}
+
+func (b *closeOnceConnBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.conn.Close() })
+	return err
+}
+
+// dialTLS dials addr and performs the uTLS handshake, manually driving the
+// relevant httptrace callbacks (the stdlib only fires these for handshakes
+// it performs itself via crypto/tls).
+func (rt *utlsRoundTripper) dialTLS(ctx context.Context, addr string, trace *httptrace.ClientTrace) (*utls.UConn, error) {
+	if trace != nil && trace.ConnectStart != nil {
+		trace.ConnectStart("tcp", addr)
+	}
+	rawConn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if trace != nil && trace.ConnectDone != nil {
+		trace.ConnectDone("tcp", addr, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	cfg := rt.tlsCfg.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+	if len(cfg.NextProtos) == 0 {
+		cfg.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+	}
+
+	uconn := utls.UClient(rawConn, cfg, rt.helloID)
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+	err = uconn.HandshakeContext(ctx)
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(toStdConnectionState(uconn.ConnectionState()), err)
+	}
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	return uconn, nil
+}
+
+// toStdConnectionState copies Version, HandshakeComplete, CipherSuite,
+// ServerName and NegotiatedProtocol (the field WithClientTrace's
+// TLSHandshakeDone callback reads) from a utls.ConnectionState into a
+// crypto/tls.ConnectionState. The two types are
+// structurally similar but distinct, so they can't be converted directly.
+func toStdConnectionState(cs utls.ConnectionState) tls.ConnectionState {
+	return tls.ConnectionState{
+		Version:            cs.Version,
+		HandshakeComplete:  cs.HandshakeComplete,
+		CipherSuite:        cs.CipherSuite,
+		ServerName:         cs.ServerName,
+		NegotiatedProtocol: cs.NegotiatedProtocol,
+	}
+}
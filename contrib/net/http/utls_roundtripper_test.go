@@ -0,0 +1,59 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package http
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+func TestToStdConnectionStateCopiesRelevantFields(t *testing.T) {
+	ucs := utls.ConnectionState{
+		Version:            utls.VersionTLS13,
+		HandshakeComplete:  true,
+		CipherSuite:        utls.TLS_AES_128_GCM_SHA256,
+		ServerName:         "example.com",
+		NegotiatedProtocol: "h2",
+	}
+
+	cs := toStdConnectionState(ucs)
+
+	assert.Equal(t, ucs.Version, cs.Version)
+	assert.Equal(t, ucs.HandshakeComplete, cs.HandshakeComplete)
+	assert.Equal(t, ucs.CipherSuite, cs.CipherSuite)
+	assert.Equal(t, ucs.ServerName, cs.ServerName)
+	assert.Equal(t, "h2", cs.NegotiatedProtocol)
+}
+
+func TestCloseOnceConnBodyClosesUnderlyingConnExactlyOnce(t *testing.T) {
+	conn := &countCloseConn{Conn: &net.TCPConn{}}
+	body := &closeOnceConnBody{ReadCloser: io.NopCloser(strings.NewReader("payload")), conn: conn}
+
+	require.NoError(t, body.Close())
+	require.NoError(t, body.Close())
+
+	assert.Equal(t, 1, conn.closes, "conn should only be closed once, even if Close is called repeatedly")
+}
+
+// countCloseConn is a net.Conn that counts Close calls without performing a
+// real close, so it's safe to embed a nil-ish *net.TCPConn for the rest of
+// the interface.
+type countCloseConn struct {
+	net.Conn
+	closes int
+}
+
+func (c *countCloseConn) Close() error {
+	c.closes++
+	return nil
+}